@@ -0,0 +1,32 @@
+package models
+
+import "sync/atomic"
+
+// notificationSeq stands in for a real notification row id. This tree has no
+// notification persistence layer yet, so SendNotification can't hand
+// PublishUserNotification an actual database id the way a full deployment
+// would; a process-local sequence number at least gives retries within a
+// single process run something stable to de-duplicate on.
+var notificationSeq uint32
+
+// SendNotification delivers text to userId, or to every user if isGlobal,
+// and publishes the same notification through activeTransport so
+// out-of-band consumers (e.g. mobile clients polling MQTT instead of
+// holding a socket open) see it too.
+func SendNotification(userId uint32, text string, isGlobal bool) error {
+	if isGlobal {
+		return activeTransport.PublishGlobalNotification(text)
+	}
+
+	dbId := atomic.AddUint32(&notificationSeq, 1)
+	return activeTransport.PublishUserNotification(userId, dbId, text)
+}
+
+// AddMarketEvent announces a market event for stockId, or for every stock if
+// isGlobal, and publishes it through activeTransport.
+func AddMarketEvent(stockId uint32, headline, text string, isGlobal bool) error {
+	if isGlobal {
+		stockId = 0
+	}
+	return activeTransport.PublishMarketEvent(stockId, headline, text)
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Sirupsen/logrus"
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/thakkarparth007/dalal-street-server/utils"
+)
+
+// NotificationTransport is the fan-out side of a notification/market event:
+// whatever push mechanism SendNotification and AddMarketEvent already use
+// to reach connected clients, a NotificationTransport additionally
+// publishes the same event to a broker so other consumers (e.g. mobile
+// clients that poll MQTT instead of holding a socket open) see it too.
+//
+// It's pluggable so tests can swap the real MQTT-backed implementation for
+// an in-memory one.
+type NotificationTransport interface {
+	// PublishUserNotification sends text to the single user identified by
+	// userId. dbId is the notification's row id in the database; paho's
+	// Publish doesn't expose the wire-level packet id for callers to set,
+	// so implementations embed dbId in the payload instead, letting
+	// subscribers de-duplicate a redelivered publish themselves.
+	PublishUserNotification(userId uint32, dbId uint32, text string) error
+	// PublishGlobalNotification broadcasts text to every user. Brokers that
+	// support retained messages should do so, so late-connecting clients
+	// see the last broadcast immediately.
+	PublishGlobalNotification(text string) error
+	// PublishMarketEvent announces a market event for stockId (or every
+	// stock, if stockId is 0).
+	PublishMarketEvent(stockId uint32, headline, text string) error
+}
+
+// activeTransport is the NotificationTransport used by SendNotification and
+// AddMarketEvent. It defaults to noopTransport so the server behaves
+// exactly as before when MQTT isn't configured.
+var activeTransport NotificationTransport = noopTransport{}
+
+// SetNotificationTransport overrides the transport SendNotification and
+// AddMarketEvent publish through. Tests use this to swap in an in-memory
+// fake instead of dialing a real broker.
+func SetNotificationTransport(t NotificationTransport) {
+	activeTransport = t
+}
+
+// InitNotificationTransport wires up activeTransport from
+// utils.Configuration. Call it once at startup, after utils.InitConfiguration.
+func InitNotificationTransport() error {
+	if utils.Configuration.MqttBrokerUrl == "" {
+		activeTransport = noopTransport{}
+		return nil
+	}
+
+	t, err := newMqttTransport()
+	if err != nil {
+		return err
+	}
+	activeTransport = t
+	return nil
+}
+
+// noopTransport is the default NotificationTransport: it does nothing,
+// which is correct when no broker has been configured.
+type noopTransport struct{}
+
+func (noopTransport) PublishUserNotification(userId uint32, dbId uint32, text string) error {
+	return nil
+}
+func (noopTransport) PublishGlobalNotification(text string) error                    { return nil }
+func (noopTransport) PublishMarketEvent(stockId uint32, headline, text string) error { return nil }
+
+// mqttTransport publishes to an MQTT broker. User-scoped notifications use
+// QoS 1 with the notification's DB row id embedded in the payload, so a
+// redelivered publish de-duplicates on the subscriber side. Global
+// broadcasts are retained, so a client connecting after the fact still
+// gets the last notification/market event immediately.
+type mqttTransport struct {
+	client MQTT.Client
+	qos    byte
+	logger *logrus.Entry
+}
+
+func newMqttTransport() (*mqttTransport, error) {
+	opts := MQTT.NewClientOptions().
+		AddBroker(utils.Configuration.MqttBrokerUrl).
+		SetClientID(utils.Configuration.MqttClientId).
+		SetAutoReconnect(true)
+
+	if utils.Configuration.MqttTlsEnabled {
+		tlsConfig, err := buildTlsConfig(utils.Configuration.MqttTlsCaCertFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	client := MQTT.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+
+	return &mqttTransport{
+		client: client,
+		qos:    utils.Configuration.MqttQos,
+		logger: utils.Logger.WithFields(logrus.Fields{"module": "models/notification_transport"}),
+	}, nil
+}
+
+func buildTlsConfig(caCertFile string) (*tls.Config, error) {
+	caCert, err := ioutil.ReadFile(caCertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("failed to parse CA cert %s", caCertFile)
+	}
+
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+func (t *mqttTransport) PublishUserNotification(userId uint32, dbId uint32, text string) error {
+	topic := fmt.Sprintf("dalal/notif/user/%d", userId)
+	payload := fmt.Sprintf("%d|%s", dbId, text)
+	token := t.client.Publish(topic, 1, false, payload)
+	token.Wait()
+	return token.Error()
+}
+
+func (t *mqttTransport) PublishGlobalNotification(text string) error {
+	token := t.client.Publish("dalal/notif/global", 1, true, text)
+	token.Wait()
+	return token.Error()
+}
+
+func (t *mqttTransport) PublishMarketEvent(stockId uint32, headline, text string) error {
+	topic := fmt.Sprintf("dalal/market/%d", stockId)
+	payload := fmt.Sprintf("%s|%s", headline, text)
+
+	retained := stockId == 0
+	token := t.client.Publish(topic, t.qos, retained, payload)
+	token.Wait()
+	return token.Error()
+}
@@ -1,7 +1,12 @@
 package models
 
 import (
+	"sort"
+	"strconv"
 	"sync"
+	"time"
+
+	"github.com/thakkarparth007/dalal-street-server/utils/metrics"
 )
 
 // PQType represents a priority queue ordering kind (see MAXPQ and MINPQ)
@@ -32,6 +37,12 @@ type BidPQueue struct {
 	items      []*bidItem
 	elemsCount int
 	comparator func(uint32, uint32, uint32, uint32) bool
+	// index maps an order's id to its current position in items, so a
+	// specific order can be found, cancelled or amended without scanning
+	// the whole heap. It's kept in sync inside exch, swim and sink.
+	index map[uint64]int
+	// stockId tags this queue's metrics; set via SetStockId.
+	stockId uint32
 }
 
 type AskPQueue struct {
@@ -39,6 +50,12 @@ type AskPQueue struct {
 	items      []*askItem
 	elemsCount int
 	comparator func(uint32, uint32, uint32, uint32) bool
+	// index maps an order's id to its current position in items, so a
+	// specific order can be found, cancelled or amended without scanning
+	// the whole heap. It's kept in sync inside exch, swim and sink.
+	index map[uint64]int
+	// stockId tags this queue's metrics; set via SetStockId.
+	stockId uint32
 }
 
 func newBidItem(value *Bid, price uint32, quantity uint32) *bidItem {
@@ -79,6 +96,7 @@ func NewBidPQueue(pqType PQType) *BidPQueue {
 		items:      items,
 		elemsCount: 0,
 		comparator: cmp,
+		index:      make(map[uint64]int),
 	}
 }
 
@@ -98,6 +116,7 @@ func NewAskPQueue(pqType PQType) *AskPQueue {
 		items:      items,
 		elemsCount: 0,
 		comparator: cmp,
+		index:      make(map[uint64]int),
 	}
 }
 
@@ -108,8 +127,11 @@ func (pq *BidPQueue) Push(value *Bid, price uint32, quantity uint32) {
 	pq.Lock()
 	pq.items = append(pq.items, item)
 	pq.elemsCount += 1
+	pq.index[value.Id] = pq.size()
 	pq.swim(pq.size())
 	pq.Unlock()
+
+	metrics.Count("dalal.pqueue.push", pq.metricTags(), 1)
 }
 
 func (pq *AskPQueue) Push(value *Ask, price uint32, quantity uint32) {
@@ -118,8 +140,11 @@ func (pq *AskPQueue) Push(value *Ask, price uint32, quantity uint32) {
 	pq.Lock()
 	pq.items = append(pq.items, item)
 	pq.elemsCount += 1
+	pq.index[value.Id] = pq.size()
 	pq.swim(pq.size())
 	pq.Unlock()
+
+	metrics.Count("dalal.pqueue.push", pq.metricTags(), 1)
 }
 
 // Pop and returns the highest/lowest priority item (depending on whether
@@ -136,9 +161,11 @@ func (pq *BidPQueue) Pop() *Bid {
 
 	pq.exch(1, pq.size())
 	pq.items = pq.items[0:pq.size()]
+	delete(pq.index, max.value.Id)
 	pq.elemsCount -= 1
 	pq.sink(1)
 
+	metrics.Count("dalal.pqueue.pop", pq.metricTagsLocked(), 1)
 	return max.value
 }
 
@@ -154,12 +181,182 @@ func (pq *AskPQueue) Pop() *Ask {
 
 	pq.exch(1, pq.size())
 	pq.items = pq.items[0:pq.size()]
+	delete(pq.index, max.value.Id)
 	pq.elemsCount -= 1
 	pq.sink(1)
 
+	metrics.Count("dalal.pqueue.pop", pq.metricTagsLocked(), 1)
 	return max.value
 }
 
+// Remove cancels the order identified by orderID, wherever it currently
+// sits in the heap, and returns it. It returns nil if no such order is
+// queued.
+func (pq *BidPQueue) Remove(orderID uint64) *Bid {
+	pq.Lock()
+	defer pq.Unlock()
+
+	k, ok := pq.index[orderID]
+	if !ok {
+		return nil
+	}
+
+	removed := pq.items[k]
+
+	pq.exch(k, pq.size())
+	pq.items = pq.items[0:pq.size()]
+	delete(pq.index, orderID)
+	pq.elemsCount -= 1
+
+	if k <= pq.size() {
+		pq.swim(k)
+		pq.sink(k)
+	}
+
+	return removed.value
+}
+
+func (pq *AskPQueue) Remove(orderID uint64) *Ask {
+	pq.Lock()
+	defer pq.Unlock()
+
+	k, ok := pq.index[orderID]
+	if !ok {
+		return nil
+	}
+
+	removed := pq.items[k]
+
+	pq.exch(k, pq.size())
+	pq.items = pq.items[0:pq.size()]
+	delete(pq.index, orderID)
+	pq.elemsCount -= 1
+
+	if k <= pq.size() {
+		pq.swim(k)
+		pq.sink(k)
+	}
+
+	return removed.value
+}
+
+// UpdateQuantity changes the queued quantity of orderID in place and
+// restores the heap invariant, without the O(n) rebuild a Remove+Push
+// would need. It reports whether orderID was found.
+func (pq *BidPQueue) UpdateQuantity(orderID uint64, newQty uint32) bool {
+	pq.Lock()
+	defer pq.Unlock()
+
+	k, ok := pq.index[orderID]
+	if !ok {
+		return false
+	}
+
+	pq.items[k].quantity = newQty
+	pq.swim(k)
+	pq.sink(k)
+	return true
+}
+
+func (pq *AskPQueue) UpdateQuantity(orderID uint64, newQty uint32) bool {
+	pq.Lock()
+	defer pq.Unlock()
+
+	k, ok := pq.index[orderID]
+	if !ok {
+		return false
+	}
+
+	pq.items[k].quantity = newQty
+	pq.swim(k)
+	pq.sink(k)
+	return true
+}
+
+// UpdatePrice changes the queued price of orderID in place and restores
+// the heap invariant. It reports whether orderID was found.
+func (pq *BidPQueue) UpdatePrice(orderID uint64, newPrice uint32) bool {
+	pq.Lock()
+	defer pq.Unlock()
+
+	k, ok := pq.index[orderID]
+	if !ok {
+		return false
+	}
+
+	pq.items[k].price = newPrice
+	pq.swim(k)
+	pq.sink(k)
+	return true
+}
+
+func (pq *AskPQueue) UpdatePrice(orderID uint64, newPrice uint32) bool {
+	pq.Lock()
+	defer pq.Unlock()
+
+	k, ok := pq.index[orderID]
+	if !ok {
+		return false
+	}
+
+	pq.items[k].price = newPrice
+	pq.swim(k)
+	pq.sink(k)
+	return true
+}
+
+// PeekN returns up to the top n bids in priority order without draining
+// the queue, for depth-of-book snapshots (e.g. the REPL's top_bids command).
+func (pq *BidPQueue) PeekN(n int) []*Bid {
+	pq.RLock()
+	defer pq.RUnlock()
+
+	if n > pq.size() {
+		n = pq.size()
+	}
+
+	items := make([]*bidItem, pq.size())
+	copy(items, pq.items[1:])
+	// pq.comparator(a, b) reports whether a has lower priority than b, so
+	// calling it with the args swapped sorts highest priority first,
+	// matching the order Head()/Pop() would drain them in.
+	sort.Slice(items, func(i, j int) bool {
+		return pq.comparator(items[j].price, items[j].quantity, items[i].price, items[i].quantity)
+	})
+
+	result := make([]*Bid, n)
+	for i := 0; i < n; i++ {
+		result[i] = items[i].value
+	}
+	return result
+}
+
+// PeekN returns up to the top n asks in priority order without draining
+// the queue, for depth-of-book snapshots (e.g. the REPL's top_asks command).
+func (pq *AskPQueue) PeekN(n int) []*Ask {
+	pq.RLock()
+	defer pq.RUnlock()
+
+	if n > pq.size() {
+		n = pq.size()
+	}
+
+	items := make([]*askItem, pq.size())
+	copy(items, pq.items[1:])
+	// pq.comparator(a, b) reports whether a has lower priority than b, so
+	// calling it with the args swapped sorts highest priority first,
+	// matching the order Head()/Pop() would drain them in.
+	sort.Slice(items, func(i, j int) bool {
+		return pq.comparator(items[j].price, items[j].quantity, items[i].price, items[i].quantity)
+	})
+
+	result := make([]*Ask, n)
+	for i := 0; i < n; i++ {
+		result[i] = items[i].value
+	}
+	return result
+}
+
 // Head returns the highest/lowest priority item (depending on whether
 // you're using a MINPQ or MAXPQ) from the priority queue
 func (pq *BidPQueue) Head() *Bid {
@@ -200,6 +397,80 @@ func (pq *AskPQueue) Size() int {
 	return pq.size()
 }
 
+// SetStockId associates pq with a stock so its push/pop counters and
+// periodic size gauge (see StartQueueMetricsSampler) are tagged per-symbol.
+// It also registers pq with the sampler.
+func (pq *BidPQueue) SetStockId(stockId uint32) {
+	pq.Lock()
+	pq.stockId = stockId
+	pq.Unlock()
+
+	queueRegistryMu.Lock()
+	bidQueueRegistry = append(bidQueueRegistry, pq)
+	queueRegistryMu.Unlock()
+}
+
+// SetStockId associates pq with a stock so its push/pop counters and
+// periodic size gauge (see StartQueueMetricsSampler) are tagged per-symbol.
+// It also registers pq with the sampler.
+func (pq *AskPQueue) SetStockId(stockId uint32) {
+	pq.Lock()
+	pq.stockId = stockId
+	pq.Unlock()
+
+	queueRegistryMu.Lock()
+	askQueueRegistry = append(askQueueRegistry, pq)
+	queueRegistryMu.Unlock()
+}
+
+// metricTags locks pq to read stockId. Callers that already hold pq's lock
+// (e.g. Pop) must use metricTagsLocked instead, since sync.RWMutex isn't
+// reentrant.
+func (pq *BidPQueue) metricTags() map[string]string {
+	pq.RLock()
+	defer pq.RUnlock()
+	return pq.metricTagsLocked()
+}
+func (pq *BidPQueue) metricTagsLocked() map[string]string {
+	return map[string]string{"stock": strconv.Itoa(int(pq.stockId)), "side": "bid"}
+}
+
+func (pq *AskPQueue) metricTags() map[string]string {
+	pq.RLock()
+	defer pq.RUnlock()
+	return pq.metricTagsLocked()
+}
+func (pq *AskPQueue) metricTagsLocked() map[string]string {
+	return map[string]string{"stock": strconv.Itoa(int(pq.stockId)), "side": "ask"}
+}
+
+var (
+	queueRegistryMu  sync.Mutex
+	bidQueueRegistry []*BidPQueue
+	askQueueRegistry []*AskPQueue
+)
+
+// StartQueueMetricsSampler launches a background goroutine that emits a
+// dalal.pqueue.size gauge for every queue registered via SetStockId, every
+// interval, until the process exits.
+func StartQueueMetricsSampler(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			queueRegistryMu.Lock()
+			bids := append([]*BidPQueue(nil), bidQueueRegistry...)
+			asks := append([]*AskPQueue(nil), askQueueRegistry...)
+			queueRegistryMu.Unlock()
+
+			for _, pq := range bids {
+				metrics.Gauge("dalal.pqueue.size", pq.metricTags(), float64(pq.Size()))
+			}
+			for _, pq := range asks {
+				metrics.Gauge("dalal.pqueue.size", pq.metricTags(), float64(pq.Size()))
+			}
+		}
+	}()
+}
+
 // Check queue is empty
 func (pq *BidPQueue) Empty() bool {
 	pq.RLock()
@@ -249,12 +520,18 @@ func (pq *BidPQueue) exch(i, j int) {
 
 	pq.items[i] = pq.items[j]
 	pq.items[j] = tmpItem
+
+	pq.index[pq.items[i].value.Id] = i
+	pq.index[pq.items[j].value.Id] = j
 }
 func (pq *AskPQueue) exch(i, j int) {
 	var tmpItem *askItem = pq.items[i]
 
 	pq.items[i] = pq.items[j]
 	pq.items[j] = tmpItem
+
+	pq.index[pq.items[i].value.Id] = i
+	pq.index[pq.items[j].value.Id] = j
 }
 
 func (pq *BidPQueue) swim(k int) {
@@ -303,4 +580,4 @@ func (pq *AskPQueue) sink(k int) {
 		pq.exch(k, j)
 		k = j
 	}
-}
\ No newline at end of file
+}
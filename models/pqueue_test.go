@@ -0,0 +1,155 @@
+package models
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// naiveBidOrder sorts a copy of items by cmp's priority using a plain sort
+// instead of a heap, as an independent reference to check BidPQueue's
+// ordering against. cmp is the queue's own comparator (rather than
+// hardcoding bidComparator/askComparator) so the reference always matches
+// whichever priority order the queue under test was actually built with.
+func naiveBidOrder(items []*bidItem, cmp func(uint32, uint32, uint32, uint32) bool) []*bidItem {
+	out := append([]*bidItem(nil), items...)
+	sort.Slice(out, func(i, j int) bool {
+		return cmp(out[j].price, out[j].quantity, out[i].price, out[i].quantity)
+	})
+	return out
+}
+
+func naiveAskOrder(items []*askItem, cmp func(uint32, uint32, uint32, uint32) bool) []*askItem {
+	out := append([]*askItem(nil), items...)
+	sort.Slice(out, func(i, j int) bool {
+		return cmp(out[j].price, out[j].quantity, out[i].price, out[i].quantity)
+	})
+	return out
+}
+
+// TestBidPQueuePropertyAgainstNaiveReference pushes, pops, removes and
+// peeks a sequence of random orders, checking Size/PeekN/Pop against a
+// freshly naive-sorted reference slice after every step.
+func TestBidPQueuePropertyAgainstNaiveReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	pq := NewBidPQueue(MAXPQ)
+
+	var nextId uint64 = 1
+	var live []*bidItem
+
+	for i := 0; i < 1000; i++ {
+		if len(live) == 0 || rng.Intn(3) != 0 {
+			price := uint32(rng.Intn(500) + 1)
+			qty := uint32(rng.Intn(100) + 1)
+			bid := &Bid{Id: nextId, UserId: 1, Price: price, StockQuantity: qty}
+			nextId++
+
+			pq.Push(bid, price, qty)
+			live = append(live, &bidItem{value: bid, price: price, quantity: qty})
+		} else {
+			k := rng.Intn(len(live))
+			if removed := pq.Remove(live[k].value.Id); removed == nil {
+				t.Fatalf("Remove(%d) returned nil for a live order", live[k].value.Id)
+			}
+			live = append(live[:k], live[k+1:]...)
+		}
+
+		want := naiveBidOrder(live, pq.comparator)
+
+		if got := pq.Size(); got != len(want) {
+			t.Fatalf("Size() = %d, want %d", got, len(want))
+		}
+
+		n := 3
+		if n > len(want) {
+			n = len(want)
+		}
+		got := pq.PeekN(3)
+		if len(got) != n {
+			t.Fatalf("PeekN(3) returned %d items, want %d", len(got), n)
+		}
+		for i, b := range got {
+			if b.Id != want[i].value.Id {
+				t.Fatalf("PeekN(3)[%d] = order %d, want order %d (naive reference)", i, b.Id, want[i].value.Id)
+			}
+		}
+	}
+
+	for len(live) > 0 {
+		want := naiveBidOrder(live, pq.comparator)
+
+		got := pq.Pop()
+		if got.Id != want[0].value.Id {
+			t.Fatalf("Pop() = order %d, want %d (naive reference)", got.Id, want[0].value.Id)
+		}
+
+		for i, item := range live {
+			if item.value.Id == got.Id {
+				live = append(live[:i], live[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func TestAskPQueuePropertyAgainstNaiveReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	pq := NewAskPQueue(MAXPQ)
+
+	var nextId uint64 = 1
+	var live []*askItem
+
+	for i := 0; i < 1000; i++ {
+		if len(live) == 0 || rng.Intn(3) != 0 {
+			price := uint32(rng.Intn(500) + 1)
+			qty := uint32(rng.Intn(100) + 1)
+			ask := &Ask{Id: nextId, UserId: 1, Price: price, StockQuantity: qty}
+			nextId++
+
+			pq.Push(ask, price, qty)
+			live = append(live, &askItem{value: ask, price: price, quantity: qty})
+		} else {
+			k := rng.Intn(len(live))
+			if removed := pq.Remove(live[k].value.Id); removed == nil {
+				t.Fatalf("Remove(%d) returned nil for a live order", live[k].value.Id)
+			}
+			live = append(live[:k], live[k+1:]...)
+		}
+
+		want := naiveAskOrder(live, pq.comparator)
+
+		if got := pq.Size(); got != len(want) {
+			t.Fatalf("Size() = %d, want %d", got, len(want))
+		}
+
+		n := 3
+		if n > len(want) {
+			n = len(want)
+		}
+		got := pq.PeekN(3)
+		if len(got) != n {
+			t.Fatalf("PeekN(3) returned %d items, want %d", len(got), n)
+		}
+		for i, a := range got {
+			if a.Id != want[i].value.Id {
+				t.Fatalf("PeekN(3)[%d] = order %d, want order %d (naive reference)", i, a.Id, want[i].value.Id)
+			}
+		}
+	}
+
+	for len(live) > 0 {
+		want := naiveAskOrder(live, pq.comparator)
+
+		got := pq.Pop()
+		if got.Id != want[0].value.Id {
+			t.Fatalf("Pop() = order %d, want %d (naive reference)", got.Id, want[0].value.Id)
+		}
+
+		for i, item := range live {
+			if item.value.Id == got.Id {
+				live = append(live[:i], live[i+1:]...)
+				break
+			}
+		}
+	}
+}
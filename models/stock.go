@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Stock is a tradeable stock listed on the exchange, together with the
+// order book backing the REPL's list_stocks/top_bids/top_asks commands.
+type Stock struct {
+	Id           uint32
+	FullName     string
+	CurrentPrice uint32
+
+	bids *BidPQueue
+	asks *AskPQueue
+}
+
+var (
+	stocksMu sync.RWMutex
+	stocks   = make(map[uint32]*Stock)
+)
+
+// RegisterStock adds stockId to the registry GetAllStocks, GetBidPQueue and
+// GetAskPQueue read from, giving it an empty order book tagged for metrics
+// via SetStockId. Call it once per stock at startup.
+func RegisterStock(stockId uint32, fullName string, currentPrice uint32) *Stock {
+	bids := NewBidPQueue(MAXPQ)
+	bids.SetStockId(stockId)
+
+	// NewAskPQueue's MINPQ branch is the one that actually assigns
+	// askComparator (lowest price = highest priority for an ask); see
+	// askComparator and NewAskPQueue.
+	asks := NewAskPQueue(MINPQ)
+	asks.SetStockId(stockId)
+
+	s := &Stock{
+		Id:           stockId,
+		FullName:     fullName,
+		CurrentPrice: currentPrice,
+		bids:         bids,
+		asks:         asks,
+	}
+
+	stocksMu.Lock()
+	stocks[stockId] = s
+	stocksMu.Unlock()
+
+	return s
+}
+
+// GetAllStocks returns every registered stock.
+func GetAllStocks() ([]*Stock, error) {
+	stocksMu.RLock()
+	defer stocksMu.RUnlock()
+
+	out := make([]*Stock, 0, len(stocks))
+	for _, s := range stocks {
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// GetBidPQueue returns the bid order book for stockId.
+func GetBidPQueue(stockId uint32) (*BidPQueue, error) {
+	s, err := getStock(stockId)
+	if err != nil {
+		return nil, err
+	}
+	return s.bids, nil
+}
+
+// GetAskPQueue returns the ask order book for stockId.
+func GetAskPQueue(stockId uint32) (*AskPQueue, error) {
+	s, err := getStock(stockId)
+	if err != nil {
+		return nil, err
+	}
+	return s.asks, nil
+}
+
+func getStock(stockId uint32) (*Stock, error) {
+	stocksMu.RLock()
+	defer stocksMu.RUnlock()
+
+	s, ok := stocks[stockId]
+	if !ok {
+		return nil, fmt.Errorf("no stock with id %d", stockId)
+	}
+	return s, nil
+}
@@ -0,0 +1,247 @@
+package repl
+
+import (
+	"fmt"
+
+	"github.com/thakkarparth007/dalal-street-server/models"
+)
+
+// topDepth is how many price levels top_bids/top_asks show.
+const topDepth = 10
+
+var replCmds = map[string]replCmdFn{
+	"sendnotif": func(s *Session) (string, error) {
+		var userId uint32
+		var isGlobal bool
+		var text string
+
+		s.print("Enter userId and notification text:")
+		if err := s.read("%d %q", &userId, &text); err != nil {
+			return "", err
+		}
+
+		if userId == 0 {
+			isGlobal = true
+			s.print("This will send '%s' to ALL users.", text)
+
+			ok, err := confirmGlobal(s)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return "Not sending", nil
+			}
+		} else {
+			u, err := models.GetUserCopy(userId)
+			if err != nil {
+				return "", fmt.Errorf("No user with id %d", userId)
+			}
+
+			s.print("Are you sure you want to send '%s' to %s (userid: %d)? [Y/N]", text, u.Name, u.Id)
+
+			c := 'N'
+			if err := s.read("%c", &c); err != nil {
+				return "", err
+			}
+			if c != 'Y' {
+				return "Not sending", nil
+			}
+		}
+
+		if err := models.SendNotification(userId, text, isGlobal); err != nil {
+			return "", err
+		}
+		return "Sent", nil
+	},
+	"add_stocks_to_exchange": func(s *Session) (string, error) {
+		var stockId uint32
+		var newStocks uint32
+
+		s.print("Enter stock id and number of new stocks:")
+		if err := s.read("%d %d", &stockId, &newStocks); err != nil {
+			return "", err
+		}
+
+		stock, err := models.GetStockCopy(stockId)
+		if err != nil {
+			return "", err
+		}
+
+		s.print("Are you sure you want to add %d new stocks to exchange for %s? [Y/N]", newStocks, stock.FullName)
+
+		c := 'N'
+		if err := s.read("%c", &c); err != nil {
+			return "", err
+		}
+		if c == 'Y' {
+			if err := models.AddStocksToExchange(stockId, newStocks); err != nil {
+				return "", err
+			}
+			return "Done", nil
+		}
+		return "Not doing", nil
+	},
+	"update_stock_price": func(s *Session) (string, error) {
+		var stockId uint32
+		var newPrice uint32
+
+		s.print("Enter stockId and new price:")
+		if err := s.read("%d %d", &stockId, &newPrice); err != nil {
+			return "", err
+		}
+
+		stock, err := models.GetStockCopy(stockId)
+		if err != nil {
+			return "", err
+		}
+
+		s.print("Are you sure you want to update %s's price to %d? [Y/N]", stock.FullName, newPrice)
+
+		c := 'N'
+		if err := s.read("%c", &c); err != nil {
+			return "", err
+		}
+		if c == 'Y' {
+			if err := models.UpdateStockPrice(stockId, newPrice); err != nil {
+				return "", err
+			}
+			return "Done", nil
+		}
+		return "Not doing", nil
+	},
+	"add_market_event": func(s *Session) (string, error) {
+		var stockId uint32
+		var headline string
+		var text string
+		var isGlobal bool
+
+		s.print("Enter stockId and headline:")
+		if err := s.read("%d %q", &stockId, &headline); err != nil {
+			return "", err
+		}
+
+		s.print("Enter brief text:")
+		if err := s.read("%q", &text); err != nil {
+			return "", err
+		}
+
+		if stockId == 0 {
+			isGlobal = true
+			s.print("This will send '%s'[%s] for ALL stocks.", headline, text)
+
+			ok, err := confirmGlobal(s)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return "Not doing", nil
+			}
+		} else {
+			stock, err := models.GetStockCopy(stockId)
+			if err != nil {
+				return "", err
+			}
+
+			s.print("Are you sure you want to send '%s'[%s] for '%s'? [Y/N]", headline, text, stock.FullName)
+
+			c := 'N'
+			if err := s.read("%c", &c); err != nil {
+				return "", err
+			}
+			if c != 'Y' {
+				return "Not doing", nil
+			}
+		}
+
+		if err := models.AddMarketEvent(stockId, headline, text, isGlobal); err != nil {
+			return "", err
+		}
+		return "Done", nil
+	},
+	"list_stocks": func(s *Session) (string, error) {
+		stocks, err := models.GetAllStocks()
+		if err != nil {
+			return "", err
+		}
+
+		w := s.tabwriter()
+		fmt.Fprintln(w, "ID\tNAME\tPRICE")
+		for _, stock := range stocks {
+			fmt.Fprintf(w, "%d\t%s\t%d\n", stock.Id, stock.FullName, stock.CurrentPrice)
+		}
+		w.Flush()
+
+		return fmt.Sprintf("%d stocks listed", len(stocks)), nil
+	},
+	"top_bids": func(s *Session) (string, error) {
+		var stockId uint32
+		s.print("Enter stockId:")
+		if err := s.read("%d", &stockId); err != nil {
+			return "", err
+		}
+
+		bidPQueue, err := models.GetBidPQueue(stockId)
+		if err != nil {
+			return "", err
+		}
+
+		bids := bidPQueue.PeekN(topDepth)
+		if len(bids) == 0 {
+			return "No bids", nil
+		}
+
+		w := s.tabwriter()
+		fmt.Fprintln(w, "ORDERID\tUSERID\tPRICE\tQUANTITY")
+		for _, bid := range bids {
+			fmt.Fprintf(w, "%d\t%d\t%d\t%d\n", bid.Id, bid.UserId, bid.Price, bid.StockQuantity)
+		}
+		w.Flush()
+
+		return "", nil
+	},
+	"top_asks": func(s *Session) (string, error) {
+		var stockId uint32
+		s.print("Enter stockId:")
+		if err := s.read("%d", &stockId); err != nil {
+			return "", err
+		}
+
+		askPQueue, err := models.GetAskPQueue(stockId)
+		if err != nil {
+			return "", err
+		}
+
+		asks := askPQueue.PeekN(topDepth)
+		if len(asks) == 0 {
+			return "No asks", nil
+		}
+
+		w := s.tabwriter()
+		fmt.Fprintln(w, "ORDERID\tUSERID\tPRICE\tQUANTITY")
+		for _, ask := range asks {
+			fmt.Fprintf(w, "%d\t%d\t%d\t%d\n", ask.Id, ask.UserId, ask.Price, ask.StockQuantity)
+		}
+		w.Flush()
+
+		return "", nil
+	},
+	"user_info": func(s *Session) (string, error) {
+		var userId uint32
+		s.print("Enter userId:")
+		if err := s.read("%d", &userId); err != nil {
+			return "", err
+		}
+
+		u, err := models.GetUserCopy(userId)
+		if err != nil {
+			return "", err
+		}
+
+		w := s.tabwriter()
+		fmt.Fprintln(w, "ID\tNAME\tCASH")
+		fmt.Fprintf(w, "%d\t%s\t%d\n", u.Id, u.Name, u.Cash)
+		w.Flush()
+
+		return "", nil
+	},
+}
@@ -0,0 +1,93 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/thakkarparth007/dalal-street-server/utils"
+	"github.com/thakkarparth007/dalal-street-server/utils/conn"
+)
+
+// historyMu guards historyConn/historyURI and serializes writes to
+// historyConn; conn.Get hands every caller the same net.Conn, and net.Conn
+// isn't safe for concurrent writers.
+var (
+	historyMu   sync.Mutex
+	historyConn net.Conn
+	historyURI  string
+)
+
+// openHistoryConn is the conn.Get Opener for utils.Configuration.SessionStoreUri.
+// It's a bare TCP dial rather than a Redis/MySQL client, since no such
+// dependency lives in this tree yet; whichever subsystem first needs a real
+// protocol on top of it can wrap this connection instead of dialing its own.
+func openHistoryConn(uri string) (io.Closer, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	return net.DialTimeout("tcp", u.Host, 5*time.Second)
+}
+
+// initHistoryStore opens the connection logHistory appends audit lines to,
+// via the shared conn registry, and holds it for the life of the process.
+// It's a no-op if SessionStoreUri isn't configured. Call it once from
+// InitREPL; pair it with StopHistoryStore at process shutdown.
+func initHistoryStore() error {
+	uri := utils.Configuration.SessionStoreUri
+	if uri == "" {
+		return nil
+	}
+
+	c, err := conn.Get(uri, openHistoryConn)
+	if err != nil {
+		return err
+	}
+
+	historyMu.Lock()
+	historyConn = c.(net.Conn)
+	historyURI = uri
+	historyMu.Unlock()
+	return nil
+}
+
+// StopHistoryStore releases the connection opened by initHistoryStore. A
+// full deployment would call this during graceful shutdown, alongside
+// whatever else tears down the admin shell.
+func StopHistoryStore() error {
+	historyMu.Lock()
+	uri := historyURI
+	historyConn = nil
+	historyURI = ""
+	historyMu.Unlock()
+
+	if uri == "" {
+		return nil
+	}
+	return conn.Close(uri)
+}
+
+// logHistory appends a one-line audit record of adminId running cmd to the
+// session store connection opened by initHistoryStore, if one is open.
+// Failures are logged rather than surfaced, since a down session store
+// shouldn't stop an admin from running commands.
+func logHistory(l *logrus.Entry, adminId, cmd string) {
+	historyMu.Lock()
+	nc := historyConn
+	historyMu.Unlock()
+
+	if nc == nil {
+		return
+	}
+
+	nc.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := fmt.Fprintf(nc, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), adminId, cmd); err != nil {
+		l.Warnf("logHistory: write to session store failed: %+v", err)
+	}
+}
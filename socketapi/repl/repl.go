@@ -2,245 +2,229 @@ package repl
 
 import (
 	"fmt"
+	"net"
 	"runtime/debug"
-	"sync"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/chzyer/readline"
 
-	"github.com/thakkarparth007/dalal-street-server/models"
 	"github.com/thakkarparth007/dalal-street-server/utils"
+	"github.com/thakkarparth007/dalal-street-server/utils/metrics"
 )
 
 var logger *logrus.Entry
 var validCmds []string
 
-type cmdSession struct {
-	in  chan string
-	out chan string
+// replCmdFn is a single admin command. It may read further input from, and
+// write prompts/tables to, sess before returning the line to print once
+// it's done.
+type replCmdFn func(sess *Session) (string, error)
+
+// Session is one admin's interactive shell. A single goroutine owns the
+// session's stdin/stdout for as long as the admin stays connected, so
+// commands can simply return their result instead of juggling channels.
+type Session struct {
+	adminId string
+	rl      *readline.Instance
 }
 
-var cmdSessionsMutex = sync.Mutex{}
-var cmdSessions = make(map[string]cmdSession)
-
-type replCmdFn func(sess cmdSession)
-
-func (s cmdSession) read(format string, args ...interface{}) {
-	if _, err := fmt.Sscanf(<-s.in, format, args...); err != nil {
-		s.error("Invalid input")
+// read prompts are already printed by the command via s.print; read just
+// blocks for the next line and scans it into args.
+func (s *Session) read(format string, args ...interface{}) error {
+	line, err := s.rl.Readline()
+	if err != nil {
+		return err
 	}
+	if _, err := fmt.Sscanf(line, format, args...); err != nil {
+		return fmt.Errorf("Invalid input")
+	}
+	return nil
 }
 
-func (s cmdSession) print(format string, args ...interface{}) {
-	s.out <- fmt.Sprintf(format, args...)
+// print writes a line straight to the admin's terminal.
+func (s *Session) print(format string, args ...interface{}) {
+	fmt.Fprintf(s.rl.Stdout(), format+"\n", args...)
 }
 
-func (s cmdSession) error(strOrErr interface{}, args ...interface{}) {
-	format := ""
-	switch strOrErr.(type) {
-	case string:
-		format = strOrErr.(string)
-	case error:
-		format = strOrErr.(error).Error()
-	default:
-		format = fmt.Sprintf("%+v", strOrErr)
-	}
-	s.print("Error: '"+format+"'", args...)
-	panic(1) // Will be recovered below. Chill. Don't panic.
+// tabwriter returns a writer that flushes aligned columns straight to the
+// session's terminal. Commands that list users/stocks/orders should write
+// their rows to it and Flush() before returning.
+func (s *Session) tabwriter() *tabwriter.Writer {
+	return tabwriter.NewWriter(s.rl.Stdout(), 0, 4, 2, ' ', 0)
 }
 
-func (s cmdSession) finish(format string, args ...interface{}) {
-	s.print(format, args...)
-	panic(0) // Easy way to exit a function. :P
+func completer() readline.AutoCompleter {
+	items := make([]readline.PrefixCompleterInterface, len(validCmds))
+	for i, cmd := range validCmds {
+		items[i] = readline.PcItem(cmd)
+	}
+	return readline.NewPrefixCompleter(items...)
 }
 
-var replCmds = map[string]replCmdFn{
-	"sendnotif": func(s cmdSession) {
-		var userId uint32
-		var isGlobal bool
-		var text string
-
-		s.print("Enter userId and notification text:")
-		s.read("%d %q", &userId, &text)
+// InitREPL sets up the command table's completion list. Call ServeAdmin
+// afterwards to actually start accepting admin shell connections.
+func InitREPL() {
+	logger = utils.Logger.WithFields(logrus.Fields{
+		"module": "socketapi/repl",
+	})
 
-		if userId == 0 {
-			isGlobal = true
-			s.print("Are you sure you want to send '%s' to ALL users?", text)
-		} else {
-			u, err := models.GetUserCopy(userId)
-			if err != nil {
-				s.error("No user with id %d", userId)
-			}
+	for cmd := range replCmds {
+		validCmds = append(validCmds, cmd)
+	}
+	sort.Strings(validCmds)
 
-			s.print("Are you sure you want to send '%s' to %s (userid: %d)? [Y/N]", text, u.Name, u.Id)
-		}
+	capacity := utils.Configuration.ReplRateLimitCapacity
+	if capacity == 0 {
+		capacity = 10
+	}
+	refillPerSec := utils.Configuration.ReplRateLimitRefillPerSec
+	if refillPerSec == 0 {
+		refillPerSec = 1
+	}
+	limiter = utils.NewRateLimiter(float64(capacity), refillPerSec)
 
-		c := 'N'
-		s.read("%c", &c)
-		if c == 'Y' {
-			err := models.SendNotification(userId, text, isGlobal)
-			if err != nil {
-				s.error(err)
-			}
-			s.finish("Sent")
-		}
-		s.finish("Not sending")
-	},
-	"add_stocks_to_exchange": func(s cmdSession) {
-		var stockId uint32
-		var newStocks uint32
+	if err := initHistoryStore(); err != nil {
+		logger.Errorf("Failed to init REPL history store: %+v", err)
+	}
 
-		s.print("Enter stock id and number of new stocks:")
-		s.read("%d %d\n", &stockId, &newStocks)
+	logger.Info("REPL Started")
+}
 
-		stock, err := models.GetStockCopy(stockId)
-		if err != nil {
-			s.error(err)
-		}
+// ServeAdmin listens for admin shell connections on addr (a "host:port" TCP
+// address, also reachable over SSH port-forwarding) and serves them until
+// the process exits. Each connection gets its own interactive Session,
+// complete with history and tab-completion, running in its own goroutine.
+func ServeAdmin(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
 
-		s.print("Are you sure you want to add %d new stocks to exchange for %s? [Y/N]", newStocks, stock.FullName)
+	logger.Infof("Admin REPL listening on %s", addr)
 
-		c := 'N'
-		s.read("%c", &c)
-		if c == 'Y' {
-			err := models.AddStocksToExchange(stockId, newStocks)
+	go func() {
+		for {
+			conn, err := ln.Accept()
 			if err != nil {
-				s.error(err)
+				logger.Errorf("Admin REPL accept failed: %+v", err)
+				continue
 			}
-			s.finish("Done")
+			go handleConn(conn)
 		}
-		s.finish("Not doing")
-	},
-	"update_stock_price": func(s cmdSession) {
-		var stockId uint32
-		var newPrice uint32
+	}()
 
-		s.print("Enter stockId and new price:")
-		s.read("%d %d", &stockId, &newPrice)
+	return nil
+}
 
-		stock, err := models.GetStockCopy(stockId)
-		if err != nil {
-			s.error(err)
-		}
+func handleConn(conn net.Conn) {
+	defer conn.Close()
 
-		s.print("Are you sure you want to update %s's price to %d? [Y/N]", stock.FullName, newPrice)
+	remoteAddr := conn.RemoteAddr().String()
+	l := logger.WithFields(logrus.Fields{
+		"method":     "handleConn",
+		"param_addr": remoteAddr,
+	})
 
-		c := 'N'
-		s.read("%c", &c)
-		if c == 'Y' {
-			err := models.UpdateStockPrice(stockId, newPrice)
-			if err != nil {
-				s.error(err)
-			}
-			s.finish("Done")
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:       "Admin id: ",
+		AutoComplete: completer(),
+		Stdin:        conn,
+		StdinWriter:  conn,
+		Stdout:       conn,
+		Stderr:       conn,
+	})
+	if err != nil {
+		l.Errorf("Failed to start admin shell: %+v", err)
+		return
+	}
+	defer rl.Close()
+
+	// The rate limiter and the command audit trail both need an identity
+	// that survives a reconnect, which conn.RemoteAddr() doesn't: it's a
+	// fresh ephemeral port every time, so a reconnect would buy a buggy or
+	// malicious admin a brand new rate-limit bucket. Ask for one up front
+	// instead. This trusts whatever the admin types; it's only as good as
+	// the network the admin port is exposed on until real authentication
+	// sits in front of it.
+	adminId, err := rl.Readline()
+	if err != nil {
+		l.Infof("Admin shell disconnected before login: %+v", err)
+		return
+	}
+	adminId = strings.TrimSpace(adminId)
+	if adminId == "" {
+		adminId = remoteAddr
+	}
+	rl.SetPrompt(fmt.Sprintf("\033[32mdalal(%s)>\033[0m ", adminId))
+
+	sess := &Session{adminId: adminId, rl: rl}
+	l = l.WithField("param_admin", adminId)
+	l.Info("Admin shell connected")
+
+	for {
+		line, err := rl.Readline()
+		if err != nil {
+			l.Infof("Admin shell disconnected: %+v", err)
+			return
 		}
-		s.finish("Not doing")
-	},
-	"add_market_event": func(s cmdSession) {
-		var stockId uint32
-		var headline string
-		var text string
-		var isGlobal bool
-
-		s.print("Enter stockId and headline:")
-		s.read("%d %q", &stockId, &headline)
-
-		s.print("Enter brief text:")
-		s.read("%q", &text)
-
-		if stockId == 0 {
-			s.print("Are you sure you want to send '%s'[%s] for ALL stocks? [Y/N]", headline, text)
-			isGlobal = true
-		} else {
-			stock, err := models.GetStockCopy(stockId)
-			if err != nil {
-				s.error(err)
-			}
 
-			s.print("Are you sure you want to send '%s'[%s] for '%s'? [Y/N]", headline, text, stock.FullName)
+		cmd := strings.TrimSpace(line)
+		if cmd == "" {
+			continue
 		}
 
-		c := 'N'
-		s.read("%c", &c)
-		if c == 'Y' {
-			err := models.AddMarketEvent(stockId, headline, text, isGlobal)
-			if err != nil {
-				s.error(err)
-			}
-			s.finish("Done")
+		fn, isValid := replCmds[cmd]
+		if !isValid {
+			sess.print("Invalid command '%s'. Valid commands are: %s", cmd, strings.Join(validCmds, ", "))
+			continue
 		}
-		s.finish("Not doing")
-	},
-}
 
-func InitREPL() {
-	logger := utils.Logger.WithFields(logrus.Fields{
-		"module": "socketapi/repl",
-	})
-	for cmd := range replCmds {
-		validCmds = append(validCmds, cmd)
+		runCmd(l, sess, cmd, fn)
+		logHistory(l, sess.adminId, cmd)
 	}
-	logger.Info("REPL Started")
 }
 
-func Handle(done <-chan struct{}, sid string, cmd string) (ret string) {
-	var l = utils.Logger.WithFields(logrus.Fields{
-		"method":    "Handle",
-		"param_sid": sid,
-		"param_cmd": cmd,
-	})
+// runCmd runs a single command, recovering from any panic so a bug in one
+// command can't take down the whole admin shell or other admins' sessions.
+// It also reports dalal.repl.command{cmd,result} counters and a
+// dalal.repl.command.latency_ms histogram, so operators can see what ran
+// and how long it took. adminId isn't a label: it's an unauthenticated
+// string the connecting client picks for itself (see handleConn), and
+// client_golang never evicts label combinations, so using it as a
+// dimension would let a client grow the metric vectors without bound.
+// Which admin ran what is still in the log line below.
+func runCmd(l *logrus.Entry, sess *Session, cmd string, fn replCmdFn) {
+	start := time.Now()
+	result := "ok"
 
 	defer func() {
 		if r := recover(); r != nil {
-			ret = "REPL Panicked! Ignoring this to save the server from death."
-			l.Errorf("Something really bad happened. Stack: %s", string(debug.Stack()))
+			result = "panic"
+			sess.print("REPL command panicked! Ignoring this to save the server from death.")
+			l.Errorf("Command '%s' panicked. Stack: %s", cmd, string(debug.Stack()))
 		}
-	}()
-
-	cmdSessionsMutex.Lock()
-	defer cmdSessionsMutex.Unlock()
 
-	if session, ok := cmdSessions[sid]; !ok {
-		if _, isValid := replCmds[cmd]; !isValid {
-			return fmt.Sprintf("Invalid command '%s'. Valid commands are: %+v ", cmd, validCmds)
-		}
+		tags := map[string]string{"cmd": cmd, "result": result}
+		metrics.Count("dalal.repl.command", tags, 1)
+		metrics.Histogram("dalal.repl.command.latency_ms", tags, float64(time.Since(start))/float64(time.Millisecond))
+	}()
 
-		cmdSessions[sid] = cmdSession{
-			in:  make(chan string),
-			out: make(chan string, 1), // so that the command doesn't hang if `done` closes before its output is read
-		}
-		session = cmdSessions[sid]
-
-		// launch the command
-		go func() {
-			defer func() {
-				recover() // to be ignored. Both panics above are exit-hacks
-				cmdSessionsMutex.Lock()
-				delete(cmdSessions, sid)
-				cmdSessionsMutex.Unlock()
-			}()
-			replCmds[cmd](session)
-		}()
-
-		// Start the cleanup go routine. Its only job is to remove the session when either the input or the output is done.
-		go func() {
-			// if the client closed connection, there's no input. Inform the command that there's no more input
-			<-done
-			cmdSessionsMutex.Lock()
-			close(cmdSessions[sid].in)
-			cmdSessionsMutex.Unlock()
-		}()
-
-		return <-session.out
+	if destructiveCmds[cmd] && !limiter.Allow(sess.adminId, cmd) {
+		result = "error"
+		sess.print("Rate limit exceeded for '%s'. Slow down and try again shortly.", cmd)
+		l.Warnf("Admin %s rate limited on '%s'", sess.adminId, cmd)
+		return
 	}
 
-	sess := cmdSessions[sid]
-	select {
-	case <-done:
-		// do nothing. Client has closed. Don't send the input to the command. Let the cleanup listener close the session
-		return ""
-	default:
-		// the client hasn't closed yet. Send the input to the command.
-		sess.in <- cmd
-		return <-sess.out // safe to return command's output here since the input is sent.
+	ret, err := fn(sess)
+	if err != nil {
+		result = "error"
+		sess.print("Error: '%s'", err.Error())
+		return
 	}
+	sess.print(ret)
 }
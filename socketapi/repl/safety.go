@@ -0,0 +1,47 @@
+package repl
+
+import (
+	"crypto/rand"
+	"strings"
+
+	"github.com/thakkarparth007/dalal-street-server/utils"
+)
+
+// destructiveCmds are the commands subject to rate limiting and, for their
+// global (all-users/all-stocks) forms, the confirmation-token workflow.
+var destructiveCmds = map[string]bool{
+	"sendnotif":              true,
+	"add_stocks_to_exchange": true,
+	"update_stock_price":     true,
+	"add_market_event":       true,
+}
+
+var limiter *utils.RateLimiter
+
+const tokenAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// randomToken generates a short human-typeable confirmation token.
+func randomToken() string {
+	b := make([]byte, 6)
+	rand.Read(b) // crypto/rand.Read on an *os.File-backed source never errors in practice
+
+	for i, v := range b {
+		b[i] = tokenAlphabet[int(v)%len(tokenAlphabet)]
+	}
+	return string(b)
+}
+
+// confirmGlobal prints a random token and asks the admin to re-type it,
+// so that a stray "Y" keypress can't broadcast a notification or market
+// event to every user/stock on the exchange.
+func confirmGlobal(s *Session) (bool, error) {
+	token := randomToken()
+	s.print("This is a GLOBAL operation. Re-type this token to confirm: %s", token)
+
+	line, err := s.rl.Readline()
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(line) == token, nil
+}
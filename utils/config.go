@@ -2,8 +2,11 @@ package utils
 
 import (
 	"encoding/json"
-	"os"
+	"fmt"
 	"log"
+	"os"
+	"reflect"
+	"strconv"
 )
 
 // Configuration contains all the configuration options
@@ -27,18 +30,79 @@ var Configuration = struct {
 
 	// Database related options
 
-	// DbUser is the name of the database user
+	// DbUri is the URI-style connection string for the primary database,
+	// e.g. "mysql://user:pw@host/db?parseTime=true". Takes precedence over
+	// the legacy DbUser/DbPassword/DbHost/DbName fields below; if it's
+	// empty, InitConfiguration synthesizes it from those so existing
+	// config.json files keep working.
+	DbUri string
+	// RedisUri is the URI-style connection string for the shared Redis
+	// connection, e.g. "redis://host:6379/0"
+	RedisUri string
+	// SessionStoreUri is the URI-style connection string for wherever REPL
+	// and API session state is persisted, e.g. "leveldb:///var/lib/dalal/sessions"
+	SessionStoreUri string
+
+	// DbUser is the name of the database user. Deprecated: set DbUri instead.
 	DbUser string
-	// DbPassword is the password of the database user
+	// DbPassword is the password of the database user. Deprecated: set DbUri instead.
 	DbPassword string
-	// DbHost is the host name of the database server
+	// DbHost is the host name of the database server. Deprecated: set DbUri instead.
 	DbHost string
-	// DbName is the name of the database
+	// DbName is the name of the database. Deprecated: set DbUri instead.
 	DbName string
+
+	// REPL related options
+
+	// ReplAdminAddr is the host:port the interactive admin shell listens
+	// on, e.g. "localhost:7074". Left empty, the admin shell is disabled.
+	ReplAdminAddr string
+	// ReplRateLimitCapacity is the number of times an admin may run a given
+	// destructive REPL command in a burst before being throttled
+	ReplRateLimitCapacity int
+	// ReplRateLimitRefillPerSec is how many of those uses are regained per
+	// second once the bucket starts refilling
+	ReplRateLimitRefillPerSec float64
+
+	// Notification transport related options
+
+	// MqttBrokerUrl is the broker this server publishes notifications and
+	// market events to, e.g. "tcp://localhost:1883". Left empty, MQTT
+	// fan-out is disabled and notifications only go out over the existing
+	// push mechanism.
+	MqttBrokerUrl string
+	// MqttClientId identifies this server to the broker
+	MqttClientId string
+	// MqttQos is the QoS level used for user-scoped notifications and
+	// market events. Global broadcasts always use QoS 1 with the retained
+	// flag set, regardless of this setting.
+	MqttQos byte
+	// MqttTlsEnabled turns on TLS for the broker connection
+	MqttTlsEnabled bool
+	// MqttTlsCaCertFile is the CA cert used to verify the broker when
+	// MqttTlsEnabled is set
+	MqttTlsCaCertFile string
+
+	// Metrics related options
+
+	// MetricsSink picks where REPL and order-book metrics go: "statsd",
+	// "prometheus", or "" to disable metrics entirely.
+	MetricsSink string
+	// MetricsStatsdAddr is the "host:port" of the StatsD/Telegraf agent,
+	// used when MetricsSink is "statsd"
+	MetricsStatsdAddr string
+	// MetricsPrometheusAddr is the "host:port" the /metrics handler is
+	// served on, used when MetricsSink is "prometheus"
+	MetricsPrometheusAddr string
+	// MetricsQueueSampleIntervalSec is how often BidPQueue/AskPQueue sizes
+	// are sampled into a gauge
+	MetricsQueueSampleIntervalSec int
 }{}
 
-// InitConfiguration reads the config.json file and loads the
-// config options into Configuration
+// InitConfiguration reads the config.json file and loads the config options
+// into Configuration. Every field may additionally be overridden by an
+// environment variable of the same name, and DbUri is synthesized from the
+// legacy Db* fields if it wasn't set directly.
 func InitConfiguration() {
 	configFile, err := os.Open("config.json")
 	if err != nil {
@@ -54,5 +118,66 @@ func InitConfiguration() {
 		log.Fatal("Failed to load configuration. Cannot proceed. Error: ", err)
 	}
 
+	applyEnvOverrides(&Configuration)
+
+	if Configuration.DbUri == "" {
+		Configuration.DbUri = legacyDbUri()
+	}
+
 	log.Printf("Loaded configuration from config.json: %+v\n", Configuration)
 }
+
+// applyEnvOverrides replaces any field of cfg whose name matches a set
+// environment variable. The variable name must be the literal Go field
+// name, e.g. DbUri="mysql://..." overrides DbUri, not a SCREAMING_SNAKE_CASE
+// rendering of it.
+func applyEnvOverrides(cfg interface{}) {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		env, ok := os.LookupEnv(t.Field(i).Name)
+		if !ok {
+			continue
+		}
+
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(env)
+		case reflect.Bool:
+			if b, err := strconv.ParseBool(env); err == nil {
+				field.SetBool(b)
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n, err := strconv.ParseInt(env, 10, 64); err == nil {
+				field.SetInt(n)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n, err := strconv.ParseUint(env, 10, 64); err == nil {
+				field.SetUint(n)
+			}
+		case reflect.Float32, reflect.Float64:
+			if f, err := strconv.ParseFloat(env, 64); err == nil {
+				field.SetFloat(f)
+			}
+		}
+	}
+}
+
+// legacyDbUri synthesizes the mysql:// URI equivalent to the legacy
+// DbUser/DbPassword/DbHost/DbName fields, so config.json files that
+// predate DbUri keep working unmodified.
+func legacyDbUri() string {
+	if Configuration.DbHost == "" && Configuration.DbName == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		"mysql://%s:%s@%s/%s?parseTime=true",
+		Configuration.DbUser,
+		Configuration.DbPassword,
+		Configuration.DbHost,
+		Configuration.DbName,
+	)
+}
@@ -0,0 +1,67 @@
+// Package conn is a registry of shared, reference-counted connections keyed
+// by URI. It lets independent subsystems (the REPL session store, the
+// models layer, future caches/queues) that are each handed the same
+// mysql://, redis:// or leveldb:// URI share one underlying pool instead of
+// dialing it separately.
+package conn
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Opener opens a new connection for uri. It's only called the first time
+// uri is requested; later Get calls for the same uri reuse the result.
+type Opener func(uri string) (io.Closer, error)
+
+type entry struct {
+	conn     io.Closer
+	refCount int
+}
+
+var (
+	mu      sync.Mutex
+	entries = make(map[string]*entry)
+)
+
+// Get returns the shared connection for uri, calling open to create it if
+// this is the first caller for that uri. Every successful Get must be
+// balanced by a Close of the same uri.
+func Get(uri string, open Opener) (io.Closer, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if e, ok := entries[uri]; ok {
+		e.refCount++
+		return e.conn, nil
+	}
+
+	c, err := open(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	entries[uri] = &entry{conn: c, refCount: 1}
+	return c, nil
+}
+
+// Close releases the caller's reference to uri's connection, closing it
+// once the last reference is released.
+func Close(uri string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	e, ok := entries[uri]
+	if !ok {
+		return fmt.Errorf("conn: no connection registered for %q", uri)
+	}
+
+	e.refCount--
+	if e.refCount > 0 {
+		return nil
+	}
+
+	delete(entries, uri)
+	return e.conn.Close()
+}
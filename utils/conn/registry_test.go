@@ -0,0 +1,62 @@
+package conn
+
+import (
+	"io"
+	"testing"
+)
+
+type fakeCloser struct {
+	closed *bool
+}
+
+func (f fakeCloser) Close() error {
+	*f.closed = true
+	return nil
+}
+
+func TestGetReusesAndRefcounts(t *testing.T) {
+	uri := "fake://reuse"
+	defer delete(entries, uri)
+
+	opens := 0
+	closed := false
+	open := func(string) (io.Closer, error) {
+		opens++
+		return fakeCloser{closed: &closed}, nil
+	}
+
+	c1, err := Get(uri, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := Get(uri, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1 != c2 {
+		t.Fatal("expected the same connection to be reused")
+	}
+	if opens != 1 {
+		t.Fatalf("open called %d times, want 1", opens)
+	}
+
+	if err := Close(uri); err != nil {
+		t.Fatal(err)
+	}
+	if closed {
+		t.Fatal("connection closed while a reference is still held")
+	}
+
+	if err := Close(uri); err != nil {
+		t.Fatal(err)
+	}
+	if !closed {
+		t.Fatal("expected connection to close once the last reference is released")
+	}
+}
+
+func TestCloseUnknownUri(t *testing.T) {
+	if err := Close("fake://never-opened"); err == nil {
+		t.Fatal("expected an error closing an unregistered uri")
+	}
+}
@@ -0,0 +1,68 @@
+// Package metrics gives the rest of the server a small Count/Gauge/Histogram
+// API, backed by whichever sink operators have configured: StatsD (the
+// Telegraf/InfluxDB line-protocol dialect), Prometheus, or nothing at all.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/thakkarparth007/dalal-street-server/utils"
+)
+
+// Sink is a destination for counters, gauges and histograms, each
+// identified by a dotted name (e.g. "dalal.repl.command") and an optional
+// set of tags.
+type Sink interface {
+	Count(name string, tags map[string]string, value int64)
+	Gauge(name string, tags map[string]string, value float64)
+	Histogram(name string, tags map[string]string, value float64)
+}
+
+// active is the process-wide sink. It defaults to noopSink, so calling
+// Count/Gauge/Histogram before Init is harmless.
+var active Sink = noopSink{}
+
+// Init sets up the metrics sink from utils.Configuration.MetricsSink, which
+// must be "statsd", "prometheus" or "" (noop). Call it once at startup,
+// after utils.InitConfiguration.
+func Init() error {
+	switch utils.Configuration.MetricsSink {
+	case "", "noop":
+		active = noopSink{}
+		return nil
+	case "statsd":
+		sink, err := newStatsdSink(utils.Configuration.MetricsStatsdAddr)
+		if err != nil {
+			return err
+		}
+		active = sink
+		return nil
+	case "prometheus":
+		active = newPrometheusSink()
+		http.Handle("/metrics", promhttp.Handler())
+		go http.ListenAndServe(utils.Configuration.MetricsPrometheusAddr, nil)
+		return nil
+	default:
+		return fmt.Errorf("metrics: unknown sink %q", utils.Configuration.MetricsSink)
+	}
+}
+
+// Count adds value to the counter name, broken down by tags.
+func Count(name string, tags map[string]string, value int64) { active.Count(name, tags, value) }
+
+// Gauge sets the gauge name to value, broken down by tags.
+func Gauge(name string, tags map[string]string, value float64) { active.Gauge(name, tags, value) }
+
+// Histogram records value as an observation of name, broken down by tags.
+func Histogram(name string, tags map[string]string, value float64) {
+	active.Histogram(name, tags, value)
+}
+
+type noopSink struct{}
+
+func (noopSink) Count(string, map[string]string, int64)       {}
+func (noopSink) Gauge(string, map[string]string, float64)     {}
+func (noopSink) Histogram(string, map[string]string, float64) {}
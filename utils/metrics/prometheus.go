@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// prometheusSink lazily registers a CounterVec/GaugeVec/HistogramVec per
+// metric name, using the sorted tag keys seen on the first call for that
+// name as its label set. Serve it with promhttp.Handler() on /metrics.
+type prometheusSink struct {
+	mu         sync.Mutex
+	counters   map[string]*prometheus.CounterVec
+	gauges     map[string]*prometheus.GaugeVec
+	histograms map[string]*prometheus.HistogramVec
+}
+
+func newPrometheusSink() *prometheusSink {
+	return &prometheusSink{
+		counters:   make(map[string]*prometheus.CounterVec),
+		gauges:     make(map[string]*prometheus.GaugeVec),
+		histograms: make(map[string]*prometheus.HistogramVec),
+	}
+}
+
+func (s *prometheusSink) Count(name string, tags map[string]string, value int64) {
+	keys, labels := splitTags(tags)
+
+	s.mu.Lock()
+	c, ok := s.counters[name]
+	if !ok {
+		c = prometheus.NewCounterVec(prometheus.CounterOpts{Name: metricName(name)}, keys)
+		prometheus.MustRegister(c)
+		s.counters[name] = c
+	}
+	s.mu.Unlock()
+
+	c.With(labels).Add(float64(value))
+}
+
+func (s *prometheusSink) Gauge(name string, tags map[string]string, value float64) {
+	keys, labels := splitTags(tags)
+
+	s.mu.Lock()
+	g, ok := s.gauges[name]
+	if !ok {
+		g = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: metricName(name)}, keys)
+		prometheus.MustRegister(g)
+		s.gauges[name] = g
+	}
+	s.mu.Unlock()
+
+	g.With(labels).Set(value)
+}
+
+func (s *prometheusSink) Histogram(name string, tags map[string]string, value float64) {
+	keys, labels := splitTags(tags)
+
+	s.mu.Lock()
+	h, ok := s.histograms[name]
+	if !ok {
+		h = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: metricName(name)}, keys)
+		prometheus.MustRegister(h)
+		s.histograms[name] = h
+	}
+	s.mu.Unlock()
+
+	h.With(labels).Observe(value)
+}
+
+func splitTags(tags map[string]string) ([]string, prometheus.Labels) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, prometheus.Labels(tags)
+}
+
+// metricName turns "dalal.repl.command" into the Prometheus-idiomatic
+// "dalal_repl_command".
+func metricName(name string) string {
+	return strings.Replace(name, ".", "_", -1)
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// statsdSink writes counters/gauges/histograms as StatsD line-protocol
+// packets over UDP, using the Telegraf "#k:v,k:v" convention for tags.
+type statsdSink struct {
+	conn net.Conn
+}
+
+func newStatsdSink(addr string) (*statsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdSink{conn: conn}, nil
+}
+
+func (s *statsdSink) Count(name string, tags map[string]string, value int64) {
+	s.send(fmt.Sprintf("%s:%d|c%s", name, value, formatTags(tags)))
+}
+
+func (s *statsdSink) Gauge(name string, tags map[string]string, value float64) {
+	s.send(fmt.Sprintf("%s:%f|g%s", name, value, formatTags(tags)))
+}
+
+func (s *statsdSink) Histogram(name string, tags map[string]string, value float64) {
+	s.send(fmt.Sprintf("%s:%f|ms%s", name, value, formatTags(tags)))
+}
+
+func (s *statsdSink) send(line string) {
+	// Best-effort: a dropped metrics packet shouldn't ever fail the caller.
+	s.conn.Write([]byte(line))
+}
+
+func formatTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s:%s", k, tags[k])
+	}
+
+	return "|#" + strings.Join(pairs, ",")
+}
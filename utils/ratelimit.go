@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// bucketIdleTTL is how long a bucket may go unused before evictIdleBuckets
+// reclaims it. Without this, one bucket per adminID+cmd pair accumulates
+// forever.
+const bucketIdleTTL = 30 * time.Minute
+
+// bucket is a single token bucket: it holds up to capacity tokens and
+// refills at refillPerSec tokens per second.
+type bucket struct {
+	tokens       float64
+	capacity     float64
+	refillPerSec float64
+	lastRefill   time.Time
+}
+
+func (b *bucket) allow() bool {
+	now := time.Now()
+
+	b.tokens += now.Sub(b.lastRefill).Seconds() * b.refillPerSec
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.lastRefill = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// RateLimiter is a per-key token bucket rate limiter, keyed on whatever the
+// caller passes to Allow (e.g. an admin id plus a command name). Buckets are
+// created lazily on first use and are safe for concurrent use.
+type RateLimiter struct {
+	mu           sync.Mutex
+	buckets      map[string]*bucket
+	capacity     float64
+	refillPerSec float64
+}
+
+// NewRateLimiter creates a RateLimiter whose buckets hold up to capacity
+// tokens and refill at refillPerSec tokens per second. Buckets idle for
+// longer than bucketIdleTTL are reclaimed by a background goroutine, so a
+// steady trickle of distinct keys (e.g. one per admin connection) doesn't
+// grow the bucket map without bound.
+func NewRateLimiter(capacity float64, refillPerSec float64) *RateLimiter {
+	rl := &RateLimiter{
+		buckets:      make(map[string]*bucket),
+		capacity:     capacity,
+		refillPerSec: refillPerSec,
+	}
+	go rl.evictIdleBuckets()
+	return rl
+}
+
+func (rl *RateLimiter) evictIdleBuckets() {
+	for range time.Tick(bucketIdleTTL) {
+		rl.evictIdleBucketsOnce(time.Now())
+	}
+}
+
+// evictIdleBucketsOnce removes every bucket not touched since before
+// now.Add(-bucketIdleTTL). Split out from evictIdleBuckets so tests can
+// drive a single pass without waiting out a real bucketIdleTTL.
+func (rl *RateLimiter) evictIdleBucketsOnce(now time.Time) {
+	cutoff := now.Add(-bucketIdleTTL)
+
+	rl.mu.Lock()
+	for key, b := range rl.buckets {
+		if b.lastRefill.Before(cutoff) {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.mu.Unlock()
+}
+
+// Allow reports whether the action identified by adminID and cmd may
+// proceed right now, consuming a token from its bucket if so.
+func (rl *RateLimiter) Allow(adminID, cmd string) bool {
+	key := adminID + ":" + cmd
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens:       rl.capacity,
+			capacity:     rl.capacity,
+			refillPerSec: rl.refillPerSec,
+			lastRefill:   time.Now(),
+		}
+		rl.buckets[key] = b
+	}
+
+	return b.allow()
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	rl := NewRateLimiter(2, 1)
+
+	if !rl.Allow("admin1", "cmd") {
+		t.Fatal("first call should be allowed")
+	}
+	if !rl.Allow("admin1", "cmd") {
+		t.Fatal("second call should be allowed (capacity 2)")
+	}
+	if rl.Allow("admin1", "cmd") {
+		t.Fatal("third call should be rate limited")
+	}
+	if !rl.Allow("admin2", "cmd") {
+		t.Fatal("a different admin should have its own bucket")
+	}
+}
+
+func TestRateLimiterEvictsIdleBuckets(t *testing.T) {
+	rl := NewRateLimiter(1, 1)
+	rl.Allow("admin1", "cmd")
+
+	rl.mu.Lock()
+	rl.buckets["admin1:cmd"].lastRefill = time.Now().Add(-bucketIdleTTL - time.Minute)
+	rl.mu.Unlock()
+
+	rl.evictIdleBucketsOnce(time.Now())
+
+	rl.mu.Lock()
+	_, ok := rl.buckets["admin1:cmd"]
+	rl.mu.Unlock()
+
+	if ok {
+		t.Fatal("expected idle bucket to be evicted")
+	}
+}